@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// rssiScanTimeout bounds the best-effort advertisement scan used to obtain
+// a device's RSSI; it is intentionally short since it only needs to see one
+// advertisement from a device that is usually scraped every couple of
+// minutes anyway.
+const rssiScanTimeout = 5 * time.Second
+
+// scanRSSI performs a short passive BLE scan for macAddress's advertisement
+// and returns the RSSI it was last seen at. It uses btmgmt rather than the
+// deprecated/removed hcitool, and - unlike a GATT characteristic read - does
+// not require or leave behind a connection to the device, so it is run
+// before connecting rather than after reading.
+func scanRSSI(ctx context.Context, macAddress, adapter string) (int, error) {
+	scanCtx, cancel := context.WithTimeout(ctx, rssiScanTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(scanCtx, "btmgmt", "--index", adapter, "find")
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return 0, fmt.Errorf("can not set up scan for %s: %s", macAddress, err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return 0, fmt.Errorf("can not start scan for %s: %s", macAddress, err)
+	}
+
+	rssi, err := findRSSI(stdout, macAddress)
+
+	_ = cmd.Process.Kill()
+	_ = cmd.Wait()
+
+	if err != nil {
+		return 0, err
+	}
+
+	return rssi, nil
+}
+
+// findRSSI reads btmgmt's "find" output until it sees a dev_found line for
+// macAddress, e.g. "hci0 dev_found: C4:7C:8D:AA:BB:CC type LE Random rssi -70 flags 0x0000".
+func findRSSI(stdout io.Reader, macAddress string) (int, error) {
+	needle := "dev_found: " + strings.ToUpper(macAddress)
+
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.Contains(line, needle) {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		for i, field := range fields {
+			if field != "rssi" || i+1 >= len(fields) {
+				continue
+			}
+
+			rssi, err := strconv.Atoi(fields[i+1])
+			if err != nil {
+				return 0, fmt.Errorf("can not parse rssi in %q: %s", line, err)
+			}
+
+			return rssi, nil
+		}
+	}
+
+	return 0, fmt.Errorf("device %s was not seen during the scan", macAddress)
+}
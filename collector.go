@@ -1,9 +1,11 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/barnybug/miflora"
@@ -18,37 +20,67 @@ const (
 )
 
 type sensorData struct {
-	Time     time.Time
-	Firmware miflora.Firmware
-	Sensors  miflora.Sensors
+	Time            time.Time
+	Firmware        miflora.Firmware
+	Sensors         miflora.Sensors
+	ConnectDuration time.Duration
+	ReadoutDuration time.Duration
+	RSSI            int
+	RSSIValid       bool
 }
 
 type flowercareCollector struct {
-	MacAddress    string
-	Device        string
-	CacheDuration time.Duration
-
-	cache               sensorData
-	upMetric            prometheus.Gauge
-	scrapeErrorsMetric  prometheus.Counter
-	scrapeTimestampDesc *prometheus.Desc
-	infoDesc            *prometheus.Desc
-	batteryDesc         *prometheus.Desc
-	conductivityDesc    *prometheus.Desc
-	lightDesc           *prometheus.Desc
-	moistureDesc        *prometheus.Desc
-	temperatureDesc     *prometheus.Desc
+	MacAddress            string
+	Device                string
+	Name                  string
+	Location              string
+	CacheDuration         time.Duration
+	MetadataCacheDuration time.Duration
+	ReadRetries           int
+	ReadTimeout           time.Duration
+	AdapterMutex          *sync.Mutex
+	MQTTPublisher         *mqttPublisher
+
+	cache                       sensorData
+	lastMetaDataFetch           time.Time
+	cachedFirmware              miflora.Firmware
+	upMetric                    prometheus.Gauge
+	scrapeErrorsMetric          prometheus.Counter
+	scrapeAttemptsMetric        prometheus.Counter
+	scrapeTimestampDesc         *prometheus.Desc
+	scrapeCollectorDurationDesc *prometheus.Desc
+	scrapeCollectorSuccessDesc  *prometheus.Desc
+	infoDesc                    *prometheus.Desc
+	batteryDesc                 *prometheus.Desc
+	conductivityDesc            *prometheus.Desc
+	lightDesc                   *prometheus.Desc
+	moistureDesc                *prometheus.Desc
+	temperatureDesc             *prometheus.Desc
+	bleConnectDurationDesc      *prometheus.Desc
+	bleReadoutDurationDesc      *prometheus.Desc
+	rssiDesc                    *prometheus.Desc
+	refreshIntervalDesc         *prometheus.Desc
+	cacheUpdatedTimeDesc        *prometheus.Desc
 }
 
-func newCollector(macAddress, device string, cacheDuration time.Duration) *flowercareCollector {
+func newCollector(macAddress, device, name, location string, cacheDuration, metadataCacheDuration, readTimeout time.Duration, readRetries int, adapterMutex *sync.Mutex, mqttPublisher *mqttPublisher) *flowercareCollector {
 	constLabels := prometheus.Labels{
 		"macaddress": strings.ToLower(macAddress),
+		"name":       name,
+		"location":   location,
 	}
 
 	return &flowercareCollector{
-		MacAddress:    macAddress,
-		Device:        device,
-		CacheDuration: cacheDuration,
+		MacAddress:            macAddress,
+		Device:                device,
+		Name:                  name,
+		Location:              location,
+		CacheDuration:         cacheDuration,
+		MetadataCacheDuration: metadataCacheDuration,
+		ReadRetries:           readRetries,
+		ReadTimeout:           readTimeout,
+		AdapterMutex:          adapterMutex,
+		MQTTPublisher:         mqttPublisher,
 
 		upMetric: prometheus.NewGauge(prometheus.GaugeOpts{
 			Name:        metricPrefix + "up",
@@ -60,10 +92,23 @@ func newCollector(macAddress, device string, cacheDuration time.Duration) *flowe
 			Help:        "Counts the number of scrape errors by this collector.",
 			ConstLabels: constLabels,
 		}),
+		scrapeAttemptsMetric: prometheus.NewCounter(prometheus.CounterOpts{
+			Name:        metricPrefix + "scrape_attempts_total",
+			Help:        "Counts the number of BLE read attempts made by this collector, including retries.",
+			ConstLabels: constLabels,
+		}),
 		scrapeTimestampDesc: prometheus.NewDesc(
 			metricPrefix+"scrape_timestamp",
 			"Contains the timestamp when the last communication with the Bluetooth device happened.",
 			nil, constLabels),
+		scrapeCollectorDurationDesc: prometheus.NewDesc(
+			metricPrefix+"scrape_collector_duration_seconds",
+			"Contains the time it took for this device's collector to complete its last scrape.",
+			nil, constLabels),
+		scrapeCollectorSuccessDesc: prometheus.NewDesc(
+			metricPrefix+"scrape_collector_success",
+			"Shows whether this device's last scrape completed without error.",
+			nil, constLabels),
 		infoDesc: prometheus.NewDesc(
 			metricPrefix+"info",
 			"Contains information about the Flower Care device.",
@@ -88,38 +133,97 @@ func newCollector(macAddress, device string, cacheDuration time.Duration) *flowe
 			metricPrefix+"temperature_celsius",
 			"Ambient temperature in celsius.",
 			nil, constLabels),
+		bleConnectDurationDesc: prometheus.NewDesc(
+			metricPrefix+"ble_connect_duration_seconds",
+			"Time taken by the first BLE read of the last refresh, which includes connecting to the device and discovering its services.",
+			nil, constLabels),
+		bleReadoutDurationDesc: prometheus.NewDesc(
+			metricPrefix+"ble_readout_duration_seconds",
+			"Time taken by the BLE reads after the first one of the last refresh; zero when only the sensors were read.",
+			nil, constLabels),
+		rssiDesc: prometheus.NewDesc(
+			metricPrefix+"rssi_dbm",
+			"Received signal strength of the device's advertisement, in dBm.",
+			nil, constLabels),
+		refreshIntervalDesc: prometheus.NewDesc(
+			metricPrefix+"refresh_interval_seconds",
+			"The configured cache duration, so dashboards can compute staleness without hardcoding it.",
+			nil, constLabels),
+		cacheUpdatedTimeDesc: prometheus.NewDesc(
+			metricPrefix+"cache_updated_time",
+			"Timestamp of the BLE read behind the currently served sample, as opposed to when it was scraped.",
+			nil, constLabels),
 	}
 }
 
 func (c *flowercareCollector) Describe(ch chan<- *prometheus.Desc) {
 	c.upMetric.Describe(ch)
 	c.scrapeErrorsMetric.Describe(ch)
+	c.scrapeAttemptsMetric.Describe(ch)
 
 	ch <- c.scrapeTimestampDesc
+	ch <- c.scrapeCollectorDurationDesc
+	ch <- c.scrapeCollectorSuccessDesc
 	ch <- c.infoDesc
 	ch <- c.batteryDesc
 	ch <- c.conductivityDesc
 	ch <- c.lightDesc
 	ch <- c.moistureDesc
 	ch <- c.temperatureDesc
+	ch <- c.bleConnectDurationDesc
+	ch <- c.bleReadoutDurationDesc
+	ch <- c.rssiDesc
+	ch <- c.refreshIntervalDesc
+	ch <- c.cacheUpdatedTimeDesc
 }
 
 func (c *flowercareCollector) Collect(ch chan<- prometheus.Metric) {
+	success := true
+
 	if time.Since(c.cache.Time) > c.CacheDuration {
+		start := time.Now()
 		data, err := c.readData()
+		duration := time.Since(start)
+
 		if err != nil {
-			log.Printf("Error during scrape: %s", err)
+			log.Printf("Error during scrape of %s: %s", c.MacAddress, err)
 
 			c.scrapeErrorsMetric.Inc()
 			c.upMetric.Set(0)
+			success = false
 		} else {
 			c.upMetric.Set(1)
 			c.cache = *data
+
+			if c.MQTTPublisher != nil {
+				if err := c.MQTTPublisher.publish(c.MacAddress, c.cache); err != nil {
+					log.Printf("Error publishing to MQTT for %s: %s", c.MacAddress, err)
+				}
+			}
+		}
+
+		if err := sendMetric(ch, c.scrapeCollectorDurationDesc, duration.Seconds()); err != nil {
+			log.Printf("Error collecting metrics: %s", err)
+		}
+	}
+
+	if err := sendMetric(ch, c.scrapeCollectorSuccessDesc, boolToFloat(success)); err != nil {
+		log.Printf("Error collecting metrics: %s", err)
+	}
+
+	if err := sendMetric(ch, c.refreshIntervalDesc, c.CacheDuration.Seconds()); err != nil {
+		log.Printf("Error collecting metrics: %s", err)
+	}
+
+	if !c.cache.Time.IsZero() {
+		if err := sendMetric(ch, c.cacheUpdatedTimeDesc, float64(c.cache.Time.Unix())); err != nil {
+			log.Printf("Error collecting metrics: %s", err)
 		}
 	}
 
 	c.upMetric.Collect(ch)
 	c.scrapeErrorsMetric.Collect(ch)
+	c.scrapeAttemptsMetric.Collect(ch)
 
 	if time.Since(c.cache.Time) < c.CacheDuration {
 		if err := c.collectData(ch, c.cache); err != nil {
@@ -128,6 +232,14 @@ func (c *flowercareCollector) Collect(ch chan<- prometheus.Metric) {
 	}
 }
 
+func boolToFloat(value bool) float64 {
+	if value {
+		return 1
+	}
+
+	return 0
+}
+
 func (c *flowercareCollector) collectData(ch chan<- prometheus.Metric, data sensorData) error {
 	if err := sendMetric(ch, c.scrapeTimestampDesc, float64(data.Time.Unix())); err != nil {
 		return err
@@ -137,10 +249,26 @@ func (c *flowercareCollector) collectData(ch chan<- prometheus.Metric, data sens
 		return err
 	}
 
+	// A failed RSSI scan leaves data.RSSI at its zero value, which reads as
+	// an excellent signal - only publish it once we actually know it.
+	if data.RSSIValid {
+		if err := sendMetric(ch, c.rssiDesc, float64(data.RSSI)); err != nil {
+			return err
+		}
+	}
+
 	for _, metric := range []struct {
 		Desc  *prometheus.Desc
 		Value float64
 	}{
+		{
+			Desc:  c.bleConnectDurationDesc,
+			Value: data.ConnectDuration.Seconds(),
+		},
+		{
+			Desc:  c.bleReadoutDurationDesc,
+			Value: data.ReadoutDuration.Seconds(),
+		},
 		{
 			Desc:  c.batteryDesc,
 			Value: float64(data.Firmware.Battery),
@@ -171,23 +299,145 @@ func (c *flowercareCollector) collectData(ch chan<- prometheus.Metric, data sens
 }
 
 func (c *flowercareCollector) readData() (*sensorData, error) {
+	// BlueZ can only talk to one peripheral at a time on a given adapter, so
+	// devices sharing an adapter must take turns. miflora's reads shell out
+	// without accepting a context, so a timed-out attempt may still be
+	// talking to the adapter in the background; the mutex must stay locked
+	// until it actually finishes, not just until we give up waiting on it.
+	c.AdapterMutex.Lock()
+
+	data, pending, err := c.readDataLocked()
+	if pending == nil {
+		c.AdapterMutex.Unlock()
+	} else {
+		go func() {
+			<-pending
+			c.AdapterMutex.Unlock()
+		}()
+	}
+
+	return data, err
+}
+
+func (c *flowercareCollector) readDataLocked() (data *sensorData, pending <-chan struct{}, err error) {
+	// RSSI comes from the advertisement, not from a GATT characteristic, so
+	// it is scanned for before connecting rather than requiring the
+	// connection from the reads below to still be open afterwards. Unlike
+	// the reads below, scanRSSI is context-aware, so it can't leak.
+	rssi, rssiErr := scanRSSI(context.Background(), c.MacAddress, c.Device)
+	if rssiErr != nil {
+		log.Printf("Could not determine RSSI for %s: %s", c.MacAddress, rssiErr)
+	}
+
 	f := miflora.NewMiflora(c.MacAddress, c.Device)
 
-	firmware, err := f.ReadFirmware()
-	if err != nil {
-		return nil, fmt.Errorf("can not read firmware: %s", err)
+	// miflora.NewMiflora only builds a handle; the actual BLE connect and
+	// service discovery happens lazily inside whichever Read* call goes out
+	// first. That first call is timed as the connect duration, and any
+	// further call (already connected) as the readout duration.
+	var connectDuration, readoutDuration time.Duration
+	firstCall := true
+
+	timedRead := func(name string, fn func() error) (error, <-chan struct{}) {
+		start := time.Now()
+		err, pending := c.retryRead(name, fn)
+		elapsed := time.Since(start)
+
+		if firstCall {
+			connectDuration = elapsed
+			firstCall = false
+		} else {
+			readoutDuration += elapsed
+		}
+
+		return err, pending
 	}
 
-	sensors, err := f.ReadSensors()
-	if err != nil {
-		return nil, fmt.Errorf("can not read sensors: %s", err)
+	firmware := c.cachedFirmware
+	if time.Since(c.lastMetaDataFetch) > c.MetadataCacheDuration {
+		var read miflora.Firmware
+
+		err, pending := timedRead("read firmware", func() error {
+			var err error
+			read, err = f.ReadFirmware()
+			return err
+		})
+		if err != nil {
+			return nil, pending, err
+		}
+
+		firmware = read
+		c.cachedFirmware = read
+		c.lastMetaDataFetch = time.Now()
+	}
+
+	var sensors miflora.Sensors
+
+	if err, pending := timedRead("read sensors", func() error {
+		var err error
+		sensors, err = f.ReadSensors()
+		return err
+	}); err != nil {
+		return nil, pending, err
 	}
 
 	return &sensorData{
-		Time:     time.Now(),
-		Firmware: firmware,
-		Sensors:  sensors,
-	}, nil
+		Time:            time.Now(),
+		Firmware:        firmware,
+		Sensors:         sensors,
+		ConnectDuration: connectDuration,
+		ReadoutDuration: readoutDuration,
+		RSSI:            rssi,
+		RSSIValid:       rssiErr == nil,
+	}, nil, nil
+}
+
+// retryRead runs fn, retrying with exponential backoff up to c.ReadRetries
+// times. Each attempt gets its own fresh c.ReadTimeout budget, as advertised
+// by the --read-timeout flag, rather than sharing one deadline across every
+// attempt of every read. fn itself is a plain synchronous miflora call that
+// does not accept a context, so each attempt runs on its own goroutine and
+// is raced against the attempt's timeout; if it fires, retryRead gives up
+// and returns immediately, but also returns the still-running attempt's done
+// channel so the caller can wait for it to actually finish before assuming
+// the adapter is free again.
+func (c *flowercareCollector) retryRead(name string, fn func() error) (error, <-chan struct{}) {
+	backoff := time.Second
+
+	var err error
+	for attempt := 0; attempt <= c.ReadRetries; attempt++ {
+		c.scrapeAttemptsMetric.Inc()
+
+		ctx, cancel := context.WithTimeout(context.Background(), c.ReadTimeout)
+		defer cancel()
+
+		done := make(chan struct{})
+		var attemptErr error
+		go func() {
+			attemptErr = fn()
+			close(done)
+		}()
+
+		select {
+		case <-done:
+			err = attemptErr
+		case <-ctx.Done():
+			return fmt.Errorf("%s: %s", name, ctx.Err()), done
+		}
+
+		if err == nil {
+			return nil, nil
+		}
+
+		if attempt == c.ReadRetries {
+			break
+		}
+
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+
+	return fmt.Errorf("can not %s after %d attempts: %s", name, c.ReadRetries+1, err), nil
 }
 
 func sendMetric(ch chan<- prometheus.Metric, desc *prometheus.Desc, value float64, labels ...string) error {
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v2"
+)
+
+// deviceConfig describes a single Flower Care sensor to scrape.
+type deviceConfig struct {
+	MacAddress string `yaml:"mac"`
+	Name       string `yaml:"name"`
+	Location   string `yaml:"location"`
+}
+
+// fileConfig is the shape of the optional YAML config file, allowing many
+// devices to be listed without repeating --device on the command line.
+type fileConfig struct {
+	Devices []deviceConfig `yaml:"devices"`
+}
+
+type config struct {
+	ListenAddr            string
+	Devices               []deviceConfig
+	ConfigFile            string
+	Device                string
+	CacheDuration         time.Duration
+	MetadataCacheDuration time.Duration
+	ReadRetries           int
+	ReadTimeout           time.Duration
+	MQTTBroker            string
+	MQTTTopicPrefix       string
+	MQTTUsername          string
+	MQTTPassword          string
+}
+
+func parseConfig() (config, error) {
+	result := config{
+		ListenAddr:            ":9294",
+		Device:                "hci0",
+		CacheDuration:         2 * time.Minute,
+		MetadataCacheDuration: 24 * time.Hour,
+		ReadRetries:           2,
+		ReadTimeout:           10 * time.Second,
+		MQTTTopicPrefix:       "flowercare",
+	}
+
+	var macAddresses []string
+
+	pflag.StringVarP(&result.ListenAddr, "addr", "a", result.ListenAddr, "Address to listen on for connections.")
+	pflag.StringArrayVarP(&macAddresses, "device", "b", nil, "MAC-Address of a Flower Care device. Can be given multiple times.")
+	pflag.StringVarP(&result.ConfigFile, "config", "f", "", "Path to a YAML file listing the Flower Care devices to scrape.")
+	pflag.StringVarP(&result.Device, "adapter", "i", result.Device, "Bluetooth device to use for communication.")
+	pflag.DurationVarP(&result.CacheDuration, "cache-duration", "c", result.CacheDuration, "Interval during which the results from the Bluetooth device are cached.")
+	pflag.DurationVar(&result.MetadataCacheDuration, "metadata-cache-duration", result.MetadataCacheDuration, "Interval during which firmware/battery information is cached, since it changes far less often than sensor readings.")
+	pflag.IntVar(&result.ReadRetries, "read-retries", result.ReadRetries, "Number of times a failed BLE read is retried before the scrape is considered an error.")
+	pflag.DurationVar(&result.ReadTimeout, "read-timeout", result.ReadTimeout, "Time to wait for a single BLE read attempt before giving up on the read. Only failed attempts are retried; a timed-out attempt is not.")
+	pflag.StringVar(&result.MQTTBroker, "mqtt-broker", result.MQTTBroker, "Address of an MQTT broker to publish readings to, e.g. tcp://localhost:1883. Disabled if empty.")
+	pflag.StringVar(&result.MQTTTopicPrefix, "mqtt-topic-prefix", result.MQTTTopicPrefix, "Topic prefix to publish readings under; each device is published to <prefix>/<macaddress>.")
+	pflag.StringVar(&result.MQTTUsername, "mqtt-username", result.MQTTUsername, "Username used to authenticate with the MQTT broker.")
+	pflag.StringVar(&result.MQTTPassword, "mqtt-password", result.MQTTPassword, "Password used to authenticate with the MQTT broker.")
+	pflag.Parse()
+
+	for _, mac := range macAddresses {
+		result.Devices = append(result.Devices, deviceConfig{MacAddress: mac})
+	}
+
+	if len(result.ConfigFile) > 0 {
+		devices, err := loadDevicesFromFile(result.ConfigFile)
+		if err != nil {
+			return result, fmt.Errorf("can not load config file: %s", err)
+		}
+
+		result.Devices = append(result.Devices, devices...)
+	}
+
+	if len(result.Devices) == 0 {
+		return result, errors.New("need to provide at least one device, either via --device or --config")
+	}
+
+	for _, device := range result.Devices {
+		if len(device.MacAddress) == 0 {
+			return result, errors.New("every device needs a MAC address")
+		}
+	}
+
+	if len(result.Device) == 0 {
+		return result, errors.New("need to provide a bluetooth device")
+	}
+
+	if err := checkTooShortInterval(result); err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+// checkTooShortInterval rejects configurations where the cache duration
+// physically cannot be met, since every device is read in turn on a shared
+// adapter, each device performs up to two retried reads per scrape (firmware
+// and sensors), and each read may take read-retries+1 attempts, each of
+// which is individually allowed to run for up to read-timeout.
+func checkTooShortInterval(c config) error {
+	const readsPerDevice = 2
+
+	attemptsPerRead := time.Duration(c.ReadRetries + 1)
+	worstCase := c.ReadTimeout * attemptsPerRead * readsPerDevice * time.Duration(len(c.Devices))
+
+	if c.CacheDuration <= worstCase {
+		return fmt.Errorf("cache-duration (%s) is too short to scrape %d device(s) with read-timeout %s and read-retries %d (worst case %s)",
+			c.CacheDuration, len(c.Devices), c.ReadTimeout, c.ReadRetries, worstCase)
+	}
+
+	return nil
+}
+
+func loadDevicesFromFile(path string) ([]deviceConfig, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var parsed fileConfig
+	if err := yaml.Unmarshal(raw, &parsed); err != nil {
+		return nil, fmt.Errorf("can not parse yaml: %s", err)
+	}
+
+	return parsed.Devices, nil
+}
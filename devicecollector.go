@@ -0,0 +1,41 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// deviceCollector fans a single Prometheus scrape out across the configured
+// Flower Care devices, collecting them concurrently. A flaky sensor only
+// affects its own metrics rather than poisoning the whole scrape.
+type deviceCollector struct {
+	collectors []*flowercareCollector
+}
+
+func newDeviceCollector(collectors []*flowercareCollector) *deviceCollector {
+	return &deviceCollector{
+		collectors: collectors,
+	}
+}
+
+func (d *deviceCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, c := range d.collectors {
+		c.Describe(ch)
+	}
+}
+
+func (d *deviceCollector) Collect(ch chan<- prometheus.Metric) {
+	var wg sync.WaitGroup
+
+	for _, c := range d.collectors {
+		wg.Add(1)
+
+		go func(c *flowercareCollector) {
+			defer wg.Done()
+			c.Collect(ch)
+		}(c)
+	}
+
+	wg.Wait()
+}
@@ -1,57 +1,36 @@
 package main
 
 import (
-	"errors"
 	"log"
 	"net/http"
-	"time"
+	"sync"
 
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/spf13/pflag"
 )
 
-type config struct {
-	ListenAddr    string
-	MacAddress    string
-	Device        string
-	CacheDuration time.Duration
-}
-
-func parseConfig() (config, error) {
-	result := config{
-		ListenAddr:    ":9294",
-		Device:        "hci0",
-		CacheDuration: 2 * time.Minute,
-	}
-
-	pflag.StringVarP(&result.ListenAddr, "addr", "a", result.ListenAddr, "Address to listen on for connections.")
-	pflag.StringVarP(&result.MacAddress, "device", "b", result.MacAddress, "MAC-Address of Flower Care device.")
-	pflag.StringVarP(&result.Device, "adapter", "i", result.Device, "Bluetooth device to use for communication.")
-	pflag.DurationVarP(&result.CacheDuration, "cache-duration", "c", result.CacheDuration, "Interval during which the results from the Bluetooth device are cached.")
-	pflag.Parse()
-
-	if len(result.MacAddress) == 0 {
-		return result, errors.New("need to provide a device address")
-	}
-
-	if len(result.Device) == 0 {
-		return result, errors.New("need to provide a bluetooth device")
-	}
-
-	return result, nil
-}
-
 func main() {
 	config, err := parseConfig()
 	if err != nil {
 		log.Fatalf("Error in configuration: %s", err)
 	}
 
-	log.Printf("Looking for %s via %s", config.MacAddress, config.Device)
-	reader := dataReader(config.MacAddress, config.Device)
+	adapterMutex := &sync.Mutex{}
+
+	var publisher *mqttPublisher
+	if len(config.MQTTBroker) > 0 {
+		publisher, err = newMQTTPublisher(config.MQTTBroker, config.MQTTUsername, config.MQTTPassword, config.MQTTTopicPrefix)
+		if err != nil {
+			log.Fatalf("Failed to connect to MQTT broker: %s", err)
+		}
+	}
+
+	var collectors []*flowercareCollector
+	for _, device := range config.Devices {
+		log.Printf("Looking for %s via %s", device.MacAddress, config.Device)
+		collectors = append(collectors, newCollector(device.MacAddress, config.Device, device.Name, device.Location, config.CacheDuration, config.MetadataCacheDuration, config.ReadTimeout, config.ReadRetries, adapterMutex, publisher))
+	}
 
-	collector := newCollector(reader, config.CacheDuration, config.MacAddress)
-	if err := prometheus.Register(collector); err != nil {
+	if err := prometheus.Register(newDeviceCollector(collectors)); err != nil {
 		log.Fatalf("Failed to register collector: %s", err)
 	}
 
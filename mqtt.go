@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// mqttPublisher pushes every successful BLE read to an MQTT broker, so a
+// single poller can feed both Prometheus (pull) and Home Assistant (push)
+// from the exact same cached read.
+type mqttPublisher struct {
+	client      mqtt.Client
+	topicPrefix string
+}
+
+func newMQTTPublisher(broker, username, password, topicPrefix string) (*mqttPublisher, error) {
+	opts := mqtt.NewClientOptions().
+		AddBroker(broker).
+		SetClientID("flowercare-exporter")
+
+	if len(username) > 0 {
+		opts.SetUsername(username)
+		opts.SetPassword(password)
+	}
+
+	client := mqtt.NewClient(opts)
+	if token := client.Connect(); token.Wait() && token.Error() != nil {
+		return nil, fmt.Errorf("can not connect to MQTT broker: %s", token.Error())
+	}
+
+	return &mqttPublisher{
+		client:      client,
+		topicPrefix: strings.TrimRight(topicPrefix, "/"),
+	}, nil
+}
+
+// mqttPayload mirrors the metrics published to Prometheus, so consumers
+// don't have to reconcile two different unit conventions.
+type mqttPayload struct {
+	Battery      int     `json:"battery"`
+	Conductivity float64 `json:"conductivity"`
+	Light        int     `json:"light"`
+	Moisture     int     `json:"moisture"`
+	Temperature  float64 `json:"temperature"`
+}
+
+func (p *mqttPublisher) publish(macAddress string, data sensorData) error {
+	payload := mqttPayload{
+		Battery:      int(data.Firmware.Battery),
+		Conductivity: float64(data.Sensors.Conductivity) * factorConductivity,
+		Light:        int(data.Sensors.Light),
+		Moisture:     int(data.Sensors.Moisture),
+		Temperature:  data.Sensors.Temperature,
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("can not marshal mqtt payload: %s", err)
+	}
+
+	topic := fmt.Sprintf("%s/%s", p.topicPrefix, strings.ToLower(macAddress))
+	if token := p.client.Publish(topic, 0, false, body); token.Wait() && token.Error() != nil {
+		return fmt.Errorf("can not publish to mqtt topic %q: %s", topic, token.Error())
+	}
+
+	return nil
+}